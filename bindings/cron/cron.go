@@ -16,6 +16,10 @@ package cron
 import (
 	"context"
 	"fmt"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -27,13 +31,38 @@ import (
 	"github.com/dapr/kit/logger"
 )
 
+// tzPrefix is the per-expression override documented for the `schedule`
+// metadata property, e.g. "TZ=Asia/Tokyo 0 30 9 * * *".
+const tzPrefix = "TZ="
+
+const (
+	leaderStatusLeader   = "leader"
+	leaderStatusFollower = "follower"
+)
+
+// scheduleEntry is one parsed cron expression from the (possibly
+// comma/newline-separated) `schedule` metadata property.
+type scheduleEntry struct {
+	expr string
+	loc  *time.Location
+	at   cron.Schedule
+}
+
 // Binding represents Cron input binding.
 type Binding struct {
-	logger   logger.Logger
-	name     string
-	schedule string
-	parser   cron.Parser
-	clk      clock.Clock
+	logger    logger.Logger
+	name      string
+	schedules []scheduleEntry
+	parser    cron.Parser
+	clk       clock.Clock
+
+	jitter        time.Duration
+	maxConcurrent int
+
+	locker         Locker
+	leaderElection bool
+	leaderMu       sync.RWMutex
+	leaderStatus   string
 }
 
 // NewCron returns a new Cron event input binding.
@@ -51,50 +80,285 @@ func NewCronWithClock(logger logger.Logger, clk clock.Clock) bindings.InputBindi
 	}
 }
 
-// Init initializes the Cron binding
+// NewCronWithLocker returns a Cron binding whose leaderElection mode, when
+// enabled via metadata, uses locker to ensure only one replica fires per
+// tick. Dapr bindings have no way to reference another component by name
+// from within Init, so the locker backend is wired up by the host
+// application at construction time instead.
+func NewCronWithLocker(logger logger.Logger, clk clock.Clock, locker Locker) bindings.InputBinding {
+	b := NewCronWithClock(logger, clk).(*Binding)
+	b.locker = locker
+	return b
+}
+
+// Init initializes the Cron binding.
 // Examples from https://godoc.org/github.com/robfig/cron:
 //
 //	"15 * * * * *" - Every 15 sec
 //	"0 30 * * * *" - Every 30 min
+//
+// `schedule` may hold more than one expression, separated by commas or
+// newlines; every one of them is registered independently and the specific
+// expression that fired is reported in ReadResponse.Metadata["schedule"].
+//
+// The binding follows the host clock's local time zone by default. Set the
+// `timeZone` metadata property to an IANA zone name (e.g. "America/New_York")
+// to pin every schedule to a specific zone, including correct DST fold/skip
+// handling. A `TZ=<zone>` prefix on an individual expression overrides
+// `timeZone` for that expression only.
+//
+// `jitter` (a duration, e.g. "30s") adds a uniformly-random 0..jitter delay
+// before each invocation of handler, to spread load when many replicas share
+// a schedule. `maxConcurrent` caps how many handler invocations can be in
+// flight at once across all schedules.
 func (b *Binding) Init(metadata bindings.Metadata) error {
 	b.name = metadata.Name
-	s, f := metadata.Properties["schedule"]
-	if !f || s == "" {
+	raw, f := metadata.Properties["schedule"]
+	if !f || raw == "" {
 		return fmt.Errorf("schedule not set")
 	}
-	_, err := b.parser.Parse(s)
+
+	var defaultLoc *time.Location
+	if tz, ok := metadata.Properties["timeZone"]; ok && tz != "" {
+		var err error
+		defaultLoc, err = time.LoadLocation(tz)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, invalid timeZone: %s", b.name, tz)
+		}
+	}
+
+	exprs, err := splitSchedules(raw)
 	if err != nil {
-		return errors.Wrapf(err, "invalid schedule format: %s", s)
+		return err
+	}
+
+	schedules := make([]scheduleEntry, 0, len(exprs))
+	for _, raw := range exprs {
+		loc, expr, err := parseScheduleTimeZone(raw)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, invalid schedule format: %s", b.name, raw)
+		}
+		if loc == nil {
+			loc = defaultLoc
+		}
+
+		at, err := b.parser.Parse(expr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid schedule format: %s", expr)
+		}
+
+		schedules = append(schedules, scheduleEntry{expr: expr, loc: loc, at: at})
+	}
+	b.schedules = schedules
+
+	if j, ok := metadata.Properties["jitter"]; ok && j != "" {
+		b.jitter, err = time.ParseDuration(j)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, invalid jitter: %s", b.name, j)
+		}
+		if b.jitter < 0 {
+			return fmt.Errorf("name: %s, jitter must not be negative", b.name)
+		}
+	}
+
+	if mc, ok := metadata.Properties["maxConcurrent"]; ok && mc != "" {
+		n, err := strconv.Atoi(mc)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, invalid maxConcurrent: %s", b.name, mc)
+		}
+		if n <= 0 {
+			return fmt.Errorf("name: %s, maxConcurrent must be positive", b.name)
+		}
+		b.maxConcurrent = n
+	}
+
+	if le, ok := metadata.Properties["leaderElection"]; ok && le != "" {
+		b.leaderElection, err = strconv.ParseBool(le)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, invalid leaderElection: %s", b.name, le)
+		}
+	}
+	if b.leaderElection && b.locker == nil {
+		return fmt.Errorf("name: %s, leaderElection requires a Locker; construct the binding with NewCronWithLocker", b.name)
 	}
-	b.schedule = s
 
 	return nil
 }
 
-// Read triggers the Cron scheduler.
+// splitSchedules breaks the `schedule` metadata property into individual
+// cron expressions on commas and newlines.
+func splitSchedules(s string) ([]string, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' })
+
+	exprs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			exprs = append(exprs, f)
+		}
+	}
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("schedule not set")
+	}
+
+	return exprs, nil
+}
+
+// parseScheduleTimeZone strips a leading "TZ=<zone>" prefix from a cron
+// expression, returning the resolved location (nil if absent) and the
+// remaining expression.
+func parseScheduleTimeZone(s string) (*time.Location, string, error) {
+	if !strings.HasPrefix(s, tzPrefix) {
+		return nil, s, nil
+	}
+
+	rest := strings.TrimPrefix(s, tzPrefix)
+	parts := strings.SplitN(rest, " ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return nil, "", fmt.Errorf("missing cron expression after %s%s", tzPrefix, parts[0])
+	}
+
+	loc, err := time.LoadLocation(parts[0])
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "unknown time zone %s", parts[0])
+	}
+
+	return loc, strings.TrimSpace(parts[1]), nil
+}
+
+// Read triggers the Cron scheduler(s). One underlying *cron.Cron is created
+// per distinct time zone among the registered schedules.
 func (b *Binding) Read(ctx context.Context, handler bindings.Handler) error {
-	c := cron.New(cron.WithParser(b.parser), cron.WithClock(b.clk))
-	id, err := c.AddFunc(b.schedule, func() {
-		b.logger.Debugf("name: %s, schedule fired: %v", b.name, time.Now())
-		handler(ctx, &bindings.ReadResponse{
-			Metadata: map[string]string{
-				"timeZone":    c.Location().String(),
-				"readTimeUTC": time.Now().UTC().String(),
-			},
+	schedulers := make(map[string]*cron.Cron)
+	schedulerFor := func(loc *time.Location) *cron.Cron {
+		key := ""
+		if loc != nil {
+			key = loc.String()
+		}
+		if c, ok := schedulers[key]; ok {
+			return c
+		}
+
+		opts := []cron.Option{cron.WithParser(b.parser), cron.WithClock(b.clk)}
+		if loc != nil {
+			opts = append(opts, cron.WithLocation(loc))
+		}
+		c := cron.New(opts...)
+		schedulers[key] = c
+		return c
+	}
+
+	var sem chan struct{}
+	if b.maxConcurrent > 0 {
+		sem = make(chan struct{}, b.maxConcurrent)
+	}
+
+	var leaseTTL time.Duration
+	if b.leaderElection {
+		now := b.clk.Now()
+		for _, entry := range b.schedules {
+			next := entry.at.Next(now)
+			interval := entry.at.Next(next).Sub(next)
+			if leaseTTL == 0 || interval < leaseTTL {
+				leaseTTL = interval
+			}
+		}
+	}
+
+	for _, entry := range b.schedules {
+		entry := entry
+		c := schedulerFor(entry.loc)
+		id, err := c.AddFunc(entry.expr, func() {
+			b.logger.Debugf("name: %s, schedule fired: %s", b.name, entry.expr)
+			go b.fire(ctx, handler, c, entry, sem, leaseTTL)
 		})
-	})
-	if err != nil {
-		return errors.Wrapf(err, "name: %s, error scheduling %s", b.name, b.schedule)
+		if err != nil {
+			return errors.Wrapf(err, "name: %s, error scheduling %s", b.name, entry.expr)
+		}
+		b.logger.Debugf("name: %s, schedule: %s, next run: %v", b.name, entry.expr, time.Until(c.Entry(id).Next))
+	}
+
+	for _, c := range schedulers {
+		c.Start()
 	}
-	c.Start()
-	b.logger.Debugf("name: %s, next run: %v", b.name, time.Until(c.Entry(id).Next))
 
 	go func() {
 		// Wait for context to be canceled
 		<-ctx.Done()
-		b.logger.Debugf("name: %s, stopping schedule: %s", b.name, b.schedule)
-		c.Stop()
+		b.logger.Debugf("name: %s, stopping schedules", b.name)
+		for _, c := range schedulers {
+			c.Stop()
+		}
+		if b.leaderElection {
+			if err := b.locker.Unlock(context.Background(), b.name); err != nil {
+				b.logger.Errorf("name: %s, error releasing lease: %v", b.name, err)
+			}
+		}
 	}()
 
 	return nil
 }
+
+// fire applies jitter and the maxConcurrent semaphore, then invokes handler
+// for a single tick of entry, gated by leader election when enabled.
+func (b *Binding) fire(ctx context.Context, handler bindings.Handler, c *cron.Cron, entry scheduleEntry, sem chan struct{}, leaseTTL time.Duration) {
+	if b.jitter > 0 {
+		delay := time.Duration(mrand.Int63n(int64(b.jitter) + 1)) //nolint:gosec
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clk.After(delay):
+		}
+	}
+
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	respMetadata := map[string]string{
+		"timeZone":    c.Location().String(),
+		"readTimeUTC": time.Now().UTC().String(),
+		"schedule":    entry.expr,
+	}
+
+	if b.leaderElection {
+		acquired, lerr := b.locker.TryLock(ctx, b.name, leaseTTL)
+		if lerr != nil {
+			b.logger.Errorf("name: %s, leader election error: %v", b.name, lerr)
+		}
+		b.setLeaderStatus(acquired)
+		if !acquired {
+			return
+		}
+		respMetadata["leaderStatus"] = leaderStatusLeader
+	}
+
+	handler(ctx, &bindings.ReadResponse{Metadata: respMetadata})
+}
+
+// LeaderStatus reports whether this instance currently believes itself to be
+// the leader. It only has meaning when leaderElection is enabled; it always
+// reports leader otherwise.
+func (b *Binding) LeaderStatus() string {
+	b.leaderMu.RLock()
+	defer b.leaderMu.RUnlock()
+	if b.leaderStatus == "" {
+		return leaderStatusLeader
+	}
+	return b.leaderStatus
+}
+
+func (b *Binding) setLeaderStatus(acquired bool) {
+	status := leaderStatusFollower
+	if acquired {
+		status = leaderStatusLeader
+	}
+	b.leaderMu.Lock()
+	b.leaderStatus = status
+	b.leaderMu.Unlock()
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/kit/logger"
+)
+
+func TestParseScheduleTimeZone(t *testing.T) {
+	t.Run("no prefix", func(t *testing.T) {
+		loc, s, err := parseScheduleTimeZone("0 30 9 * * *")
+		require.NoError(t, err)
+		assert.Nil(t, loc)
+		assert.Equal(t, "0 30 9 * * *", s)
+	})
+
+	t.Run("valid prefix", func(t *testing.T) {
+		loc, s, err := parseScheduleTimeZone("TZ=Asia/Tokyo 0 30 9 * * *")
+		require.NoError(t, err)
+		require.NotNil(t, loc)
+		assert.Equal(t, "Asia/Tokyo", loc.String())
+		assert.Equal(t, "0 30 9 * * *", s)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		_, _, err := parseScheduleTimeZone("TZ=Not/AZone 0 30 9 * * *")
+		assert.Error(t, err)
+	})
+
+	t.Run("missing expression after prefix", func(t *testing.T) {
+		_, _, err := parseScheduleTimeZone("TZ=Asia/Tokyo")
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitSchedules(t *testing.T) {
+	t.Run("single schedule", func(t *testing.T) {
+		exprs, err := splitSchedules("0 30 9 * * *")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0 30 9 * * *"}, exprs)
+	})
+
+	t.Run("comma separated", func(t *testing.T) {
+		exprs, err := splitSchedules("0 30 9 * * * , 0 0 12 * * *")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0 30 9 * * *", "0 0 12 * * *"}, exprs)
+	})
+
+	t.Run("newline separated", func(t *testing.T) {
+		exprs, err := splitSchedules("0 30 9 * * *\n0 0 12 * * *\n")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0 30 9 * * *", "0 0 12 * * *"}, exprs)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := splitSchedules(" , \n ")
+		assert.Error(t, err)
+	})
+}
+
+func TestInitTimeZone(t *testing.T) {
+	t.Run("defaults to host clock when unset", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule": "0 30 9 * * *",
+		}}})
+		require.NoError(t, err)
+		require.Len(t, b.schedules, 1)
+		assert.Nil(t, b.schedules[0].loc)
+	})
+
+	t.Run("timeZone metadata is applied", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule": "0 30 9 * * *",
+			"timeZone": "America/New_York",
+		}}})
+		require.NoError(t, err)
+		require.Len(t, b.schedules, 1)
+		require.NotNil(t, b.schedules[0].loc)
+		assert.Equal(t, "America/New_York", b.schedules[0].loc.String())
+	})
+
+	t.Run("unknown timeZone metadata errors", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule": "0 30 9 * * *",
+			"timeZone": "Not/AZone",
+		}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("per-expression TZ= overrides timeZone metadata", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule": "TZ=Asia/Tokyo 0 30 9 * * *",
+			"timeZone": "America/New_York",
+		}}})
+		require.NoError(t, err)
+		require.Len(t, b.schedules, 1)
+		require.NotNil(t, b.schedules[0].loc)
+		assert.Equal(t, "Asia/Tokyo", b.schedules[0].loc.String())
+	})
+}
+
+func TestInitMultipleSchedulesJitterAndMaxConcurrent(t *testing.T) {
+	b := newTestBinding(t)
+	err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+		"schedule":      "0 30 9 * * *,TZ=Asia/Tokyo 0 0 12 * * *",
+		"jitter":        "5s",
+		"maxConcurrent": "2",
+	}}})
+	require.NoError(t, err)
+	require.Len(t, b.schedules, 2)
+	assert.Nil(t, b.schedules[0].loc)
+	require.NotNil(t, b.schedules[1].loc)
+	assert.Equal(t, "Asia/Tokyo", b.schedules[1].loc.String())
+	assert.Equal(t, 5*time.Second, b.jitter)
+	assert.Equal(t, 2, b.maxConcurrent)
+
+	t.Run("invalid jitter", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule": "0 30 9 * * *",
+			"jitter":   "notaduration",
+		}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("non-positive maxConcurrent", func(t *testing.T) {
+		b := newTestBinding(t)
+		err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+			"schedule":      "0 30 9 * * *",
+			"maxConcurrent": "0",
+		}}})
+		assert.Error(t, err)
+	})
+}
+
+// TestReadAcrossDSTBoundary fires a schedule that crosses the US
+// spring-forward transition (2024-03-10 02:00 -> 03:00 America/New_York) and
+// verifies the handler still fires within the following 25 hours and reports
+// the configured zone in its metadata.
+func TestReadAcrossDSTBoundary(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	mock := clock.NewMock()
+	start := time.Date(2024, 3, 10, 1, 59, 0, 0, loc)
+	mock.Set(start)
+
+	b := NewCronWithClock(logger.NewLogger("test"), mock).(*Binding)
+	err = b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+		"schedule": "0 30 2 * * *",
+		"timeZone": "America/New_York",
+	}}})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var fired []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = b.Read(ctx, func(_ context.Context, in *bindings.ReadResponse) ([]byte, error) {
+		mu.Lock()
+		fired = append(fired, in.Metadata["timeZone"])
+		mu.Unlock()
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	// 02:30 does not exist on this date (clocks skip 02:00 -> 03:00), so the
+	// next occurrence should land on 2024-03-11 02:30 local time.
+	mock.Add(25 * time.Hour)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(fired) > 0
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "America/New_York", fired[0])
+}
+
+// TestReadMultipleSchedulesReportWhichFired registers two schedules and
+// asserts each tick's ReadResponse.Metadata carries the expression that
+// actually fired.
+func TestReadMultipleSchedulesReportWhichFired(t *testing.T) {
+	mock := clock.NewMock()
+	mock.Set(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	b := NewCronWithClock(logger.NewLogger("test"), mock).(*Binding)
+	err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+		"schedule": "0 * * * * *\n30 * * * * *",
+	}}})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var fired []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = b.Read(ctx, func(_ context.Context, in *bindings.ReadResponse) ([]byte, error) {
+		mu.Lock()
+		fired = append(fired, in.Metadata["schedule"])
+		mu.Unlock()
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	mock.Add(90 * time.Second)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(fired) >= 2
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{"0 * * * * *", "30 * * * * *"}, fired)
+}
+
+// fakeLocker is a Locker whose acquired state is controlled directly by the
+// test, so leaderElection's effect on Read/fire can be exercised without a
+// real backend.
+type fakeLocker struct {
+	mu       sync.Mutex
+	acquired bool
+}
+
+func (f *fakeLocker) TryLock(_ context.Context, _ string, _ time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.acquired, nil
+}
+
+func (f *fakeLocker) Unlock(_ context.Context, _ string) error { return nil }
+
+func (f *fakeLocker) setAcquired(v bool) {
+	f.mu.Lock()
+	f.acquired = v
+	f.mu.Unlock()
+}
+
+// TestReadSkipsHandlerWhileLeaseHeldElsewhereThenResumes verifies that, with
+// leaderElection enabled, fire skips the handler on ticks where the Locker
+// reports the lease is held elsewhere, and resumes firing once it's
+// acquirable.
+func TestReadSkipsHandlerWhileLeaseHeldElsewhereThenResumes(t *testing.T) {
+	mock := clock.NewMock()
+	mock.Set(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	locker := &fakeLocker{acquired: false}
+	b := NewCronWithLocker(logger.NewLogger("test"), mock, locker).(*Binding)
+	err := b.Init(bindings.Metadata{Base: metadata.Base{Properties: map[string]string{
+		"schedule":       "0 * * * * *",
+		"leaderElection": "true",
+	}}})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var fired int
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = b.Read(ctx, func(_ context.Context, _ *bindings.ReadResponse) ([]byte, error) {
+		mu.Lock()
+		fired++
+		mu.Unlock()
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	mock.Add(60 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, 0, fired, "handler must not fire while another instance holds the lease")
+	mu.Unlock()
+	assert.Equal(t, leaderStatusFollower, b.LeaderStatus())
+
+	locker.setAcquired(true)
+	mock.Add(60 * time.Second)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return fired > 0
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, leaderStatusLeader, b.LeaderStatus())
+}
+
+func newTestBinding(t *testing.T) *Binding {
+	t.Helper()
+	return NewCronWithClock(logger.NewLogger("test"), clock.New()).(*Binding)
+}
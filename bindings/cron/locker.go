@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"context"
+	"time"
+)
+
+// Locker is implemented by pluggable backends that give the Cron binding
+// distributed mutual exclusion, so that only one Dapr replica invokes the
+// handler for a given scheduled tick when leaderElection is enabled.
+//
+// Implementations must be safe to call repeatedly: Read calls TryLock once
+// per tick (never holding a goroutine across ticks), so a backend that loses
+// its lease is re-acquired on a later tick without any special handling.
+type Locker interface {
+	// TryLock attempts to acquire (or renew, if already held by this
+	// instance) the named lease for ttl. It returns true if the lease is
+	// held by this instance after the call.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+
+	// Unlock releases the named lease if held by this instance. It is a
+	// no-op if the lease is not held.
+	Unlock(ctx context.Context, name string) error
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"context"
+	"time"
+)
+
+// PGAdvisoryLocker is satisfied by state/postgresql.PostgresDBAccess, which
+// holds the connection pool this backend reuses so a single Postgres state
+// store doubles as the leader-election lock. Advisory locks are
+// session-level (pg_try_advisory_lock/pg_advisory_unlock), so ttl is unused:
+// a held lock is naturally released if the holding connection disconnects.
+//
+// Because the lock is scoped to the backend connection that acquired it,
+// not to the pool as a whole, implementations must pin a single connection
+// across a TryAdvisoryLock/AdvisoryUnlock pair for a given name rather than
+// issuing each call against the shared pool; otherwise AdvisoryUnlock can
+// run on a different connection than the one holding the lock and never
+// actually release it.
+type PGAdvisoryLocker interface {
+	TryAdvisoryLock(ctx context.Context, name string) (bool, error)
+	AdvisoryUnlock(ctx context.Context, name string) error
+}
+
+// PostgresLocker is a Locker backed by a Postgres advisory lock, acquired
+// with pg_try_advisory_lock(hashtext(name)).
+type PostgresLocker struct {
+	db PGAdvisoryLocker
+}
+
+// NewPostgresLocker returns a Locker that leases name via a Postgres
+// advisory lock held on db's connection pool.
+func NewPostgresLocker(db PGAdvisoryLocker) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+func (l *PostgresLocker) TryLock(ctx context.Context, name string, _ time.Duration) (bool, error) {
+	return l.db.TryAdvisoryLock(ctx, name)
+}
+
+func (l *PostgresLocker) Unlock(ctx context.Context, name string) error {
+	return l.db.AdvisoryUnlock(ctx, name)
+}
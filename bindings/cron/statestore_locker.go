@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// StateStoreLocker is a Locker backed by a single row in a Dapr state store,
+// compare-and-swap'd on its ETag. It works with any state store that
+// supports first-write-wins concurrency, at the cost of one Get and (unless
+// another replica holds a live lease) one Set per tick.
+type StateStoreLocker struct {
+	store      state.Store
+	instanceID string
+}
+
+// NewStateStoreLocker returns a Locker that leases "<name>/lease" rows in
+// store. Each process gets a random instanceID so that lease renewal can
+// tell its own holds apart from another replica's.
+func NewStateStoreLocker(store state.Store) *StateStoreLocker {
+	return &StateStoreLocker{
+		store:      store,
+		instanceID: newInstanceID(),
+	}
+}
+
+type leaseRecord struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func leaseKey(name string) string {
+	return name + "/lease"
+}
+
+func (l *StateStoreLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	key := leaseKey(name)
+
+	resp, err := l.store.Get(ctx, &state.GetRequest{Key: key})
+	if err != nil {
+		return false, fmt.Errorf("cron: failed reading lease %s: %w", key, err)
+	}
+
+	now := time.Now()
+	var existing leaseRecord
+	if len(resp.Data) > 0 {
+		if err = json.Unmarshal(resp.Data, &existing); err != nil {
+			return false, fmt.Errorf("cron: failed parsing lease %s: %w", key, err)
+		}
+		if existing.Holder != l.instanceID && existing.ExpiresAt.After(now) {
+			// Another replica holds a live lease.
+			return false, nil
+		}
+	}
+
+	record := leaseRecord{Holder: l.instanceID, ExpiresAt: now.Add(ttl)}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, fmt.Errorf("cron: failed encoding lease %s: %w", key, err)
+	}
+
+	err = l.store.Set(ctx, &state.SetRequest{
+		Key:     key,
+		Value:   data,
+		ETag:    resp.ETag,
+		Options: state.SetStateOption{Concurrency: state.FirstWrite},
+	})
+	if err != nil {
+		var etagErr *state.ETagError
+		if errors.As(err, &etagErr) && etagErr.Kind() == state.ETagMismatch {
+			// Lost the race to another replica.
+			return false, nil
+		}
+		return false, fmt.Errorf("cron: failed acquiring lease %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (l *StateStoreLocker) Unlock(ctx context.Context, name string) error {
+	key := leaseKey(name)
+
+	resp, err := l.store.Get(ctx, &state.GetRequest{Key: key})
+	if err != nil {
+		return fmt.Errorf("cron: failed reading lease %s: %w", key, err)
+	}
+	if len(resp.Data) == 0 {
+		return nil
+	}
+
+	var existing leaseRecord
+	if err = json.Unmarshal(resp.Data, &existing); err != nil {
+		return fmt.Errorf("cron: failed parsing lease %s: %w", key, err)
+	}
+	if existing.Holder != l.instanceID {
+		return nil
+	}
+
+	if err = l.store.Delete(ctx, &state.DeleteRequest{Key: key, ETag: resp.ETag}); err != nil {
+		return fmt.Errorf("cron: failed releasing lease %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func newInstanceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is effectively unrecoverable; fall back to a
+		// fixed id rather than panicking the binding.
+		return "cron-instance"
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// fakeStateStore is a minimal in-memory state.Store: a single row guarded by
+// a mutex, enforcing ETag compare-and-swap on Set/Delete the way a real
+// first-write-wins store would. It's enough to exercise StateStoreLocker
+// without a real state store component.
+type fakeStateStore struct {
+	mu   sync.Mutex
+	set  bool
+	etag int64
+	data []byte
+}
+
+func (f *fakeStateStore) Init(metadata state.Metadata) error { return nil }
+func (f *fakeStateStore) Close() error                       { return nil }
+
+func (f *fakeStateStore) Get(_ context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.set {
+		return &state.GetResponse{}, nil
+	}
+	etag := strconv.FormatInt(f.etag, 10)
+	return &state.GetResponse{Data: f.data, ETag: &etag}, nil
+}
+
+func (f *fakeStateStore) Set(_ context.Context, req *state.SetRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.set {
+		if req.ETag == nil || *req.ETag == "" {
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("key already exists"))
+		}
+		etag, err := strconv.ParseInt(*req.ETag, 10, 64)
+		if err != nil || etag != f.etag {
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch"))
+		}
+	}
+
+	data, ok := req.Value.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte value, got %T", req.Value)
+	}
+	f.data = data
+	f.etag++
+	f.set = true
+
+	return nil
+}
+
+func (f *fakeStateStore) Delete(_ context.Context, req *state.DeleteRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.set {
+		return nil
+	}
+	if req.ETag != nil && *req.ETag != "" {
+		etag, err := strconv.ParseInt(*req.ETag, 10, 64)
+		if err != nil || etag != f.etag {
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch"))
+		}
+	}
+	f.set = false
+	f.data = nil
+
+	return nil
+}
+
+func (f *fakeStateStore) BulkGet(_ context.Context, req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeStateStore) BulkSet(_ context.Context, req []state.SetRequest) error { return nil }
+
+func (f *fakeStateStore) BulkDelete(_ context.Context, req []state.DeleteRequest) error { return nil }
+
+func TestStateStoreLockerRenewsOwnLease(t *testing.T) {
+	locker := NewStateStoreLocker(&fakeStateStore{})
+
+	acquired, err := locker.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// The same instance renews its own lease even though it hasn't expired.
+	acquired, err = locker.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestStateStoreLockerTakeoverAfterExpiry(t *testing.T) {
+	store := &fakeStateStore{}
+	holder := NewStateStoreLocker(store)
+	other := NewStateStoreLocker(store)
+
+	// A negative ttl leaves the lease already expired as of now.
+	acquired, err := holder.TryLock(context.Background(), "job", -time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = other.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "a different instance should take over an expired lease")
+}
+
+func TestStateStoreLockerUnlockReleasesForOthers(t *testing.T) {
+	store := &fakeStateStore{}
+	holder := NewStateStoreLocker(store)
+	other := NewStateStoreLocker(store)
+
+	acquired, err := holder.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = other.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "the lease is still live and held by another instance")
+
+	require.NoError(t, holder.Unlock(context.Background(), "job"))
+
+	acquired, err = other.TryLock(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "the lease should be available once released")
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgresql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdvisoryLockTracksPerName guards against the connection-pinning fix
+// regressing to a single shared slot: holding two different advisory locks
+// concurrently and releasing one must not affect the other, since each name
+// is held on its own dedicated connection rather than a connection shared
+// across names.
+func TestAdvisoryLockTracksPerName(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectQuery("pg_try_advisory_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	m.mock.ExpectQuery("pg_try_advisory_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+
+	acquired, err := m.pgDba.TryAdvisoryLock(context.Background(), "lock-a")
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = m.pgDba.TryAdvisoryLock(context.Background(), "lock-b")
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.Len(t, m.pgDba.advisoryLockConns, 2)
+
+	m.mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, m.pgDba.AdvisoryUnlock(context.Background(), "lock-a"))
+
+	_, aHeld := m.pgDba.advisoryLockConns["lock-a"]
+	assert.False(t, aHeld, "lock-a should be released")
+	_, bHeld := m.pgDba.advisoryLockConns["lock-b"]
+	assert.True(t, bHeld, "unlocking lock-a must not release lock-b")
+
+	assert.NoError(t, m.mock.ExpectationsWereMet())
+}
+
+// TestAdvisoryLockRenewsWithoutNewQuery verifies a second TryAdvisoryLock
+// call for a name this instance already holds is a no-op rather than
+// acquiring (and pinning) a second connection for the same name.
+func TestAdvisoryLockRenewsWithoutNewQuery(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectQuery("pg_try_advisory_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+
+	acquired, err := m.pgDba.TryAdvisoryLock(context.Background(), "lock-a")
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = m.pgDba.TryAdvisoryLock(context.Background(), "lock-a")
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	assert.Len(t, m.pgDba.advisoryLockConns, 1)
+	assert.NoError(t, m.mock.ExpectationsWereMet())
+}
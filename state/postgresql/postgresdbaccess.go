@@ -0,0 +1,614 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	// Blank import for pgx
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	defaultTableName       = "state"
+	defaultCleanupInternal = 3600
+
+	// pgUniqueViolationCode is the Postgres SQLSTATE for a unique constraint
+	// violation, e.g. inserting a key that already exists in the FirstWrite
+	// path below.
+	pgUniqueViolationCode = "23505"
+)
+
+var errMissingConnectionString = errors.New("missing connection string")
+
+type postgresMetadataStruct struct {
+	ConnectionString string
+	TableName        string
+	QueryIndexKeys   []string
+}
+
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx, letting setValue and
+// deleteValue run either standalone or inside a caller-managed transaction.
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PostgresDBAccess implements the state.Store CRUD operations against a
+// Postgres table, storing values as JSONB rows keyed by the state key.
+type PostgresDBAccess struct {
+	logger logger.Logger
+	db     *sql.DB
+
+	metadata        postgresMetadataStruct
+	cleanupInterval *time.Duration
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	// advisoryLockConns holds one dedicated connection per currently-held
+	// advisory lock name, since a session-level lock is scoped to the
+	// specific connection that acquired it. A single PostgresDBAccess can
+	// back more than one concurrently-held lock (its own TTL-cleanup lock
+	// plus a bindings/cron PostgresLocker, or several cron bindings sharing
+	// one locker), so locks must be tracked per name rather than in a single
+	// shared slot: closing the connection for one name must never affect a
+	// different name's still-held lock.
+	advisoryLockMu    sync.Mutex
+	advisoryLockConns map[string]*sql.Conn
+}
+
+// NewPostgresDBAccess creates a new instance of PostgresDBAccess.
+func NewPostgresDBAccess(logger logger.Logger) *PostgresDBAccess {
+	return &PostgresDBAccess{logger: logger}
+}
+
+// ParseMetadata parses the component metadata into the fields PostgresDBAccess needs.
+func (p *PostgresDBAccess) ParseMetadata(meta state.Metadata) error {
+	m := postgresMetadataStruct{TableName: defaultTableName}
+
+	if cs, ok := meta.Properties["connectionString"]; ok && cs != "" {
+		m.ConnectionString = cs
+	} else {
+		return errMissingConnectionString
+	}
+
+	if tn, ok := meta.Properties["tableName"]; ok && tn != "" {
+		m.TableName = tn
+	}
+
+	if qk, ok := meta.Properties["queryIndexKeys"]; ok && qk != "" {
+		for _, k := range strings.Split(qk, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				m.QueryIndexKeys = append(m.QueryIndexKeys, k)
+			}
+		}
+	}
+
+	p.metadata = m
+
+	if raw, ok := meta.Properties["cleanupIntervalInSeconds"]; ok && raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid cleanupIntervalInSeconds: %w", err)
+		}
+		if seconds > 0 {
+			d := time.Duration(seconds) * time.Second
+			p.cleanupInterval = &d
+		} else {
+			p.cleanupInterval = nil
+		}
+	} else {
+		d := defaultCleanupInternal * time.Second
+		p.cleanupInterval = &d
+	}
+
+	return nil
+}
+
+// Init parses metadata, opens the connection pool and ensures the state table exists.
+func (p *PostgresDBAccess) Init(meta state.Metadata) error {
+	if err := p.ParseMetadata(meta); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("pgx", p.metadata.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to create postgres db connection: %w", err)
+	}
+
+	if err = db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping postgres: %w", err)
+	}
+	p.db = db
+
+	if err = p.ensureStateTable(context.Background()); err != nil {
+		return err
+	}
+
+	if err = p.ensureQueryIndex(context.Background()); err != nil {
+		return err
+	}
+
+	p.closeCh = make(chan struct{})
+	p.startCleanupTimer()
+
+	return nil
+}
+
+// startCleanupTimer runs a background loop that deletes expired rows every
+// cleanupInterval. Multiple sidecars sharing a table coordinate via a
+// Postgres advisory lock so only one of them runs the DELETE per tick.
+func (p *PostgresDBAccess) startCleanupTimer() {
+	if p.cleanupInterval == nil {
+		return
+	}
+
+	ticker := time.NewTicker(*p.cleanupInterval)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.closeCh:
+				return
+			case <-ticker.C:
+				p.cleanupExpired()
+			}
+		}
+	}()
+}
+
+func (p *PostgresDBAccess) cleanupExpired() {
+	ctx := context.Background()
+
+	acquired, err := p.TryAdvisoryLock(ctx, p.metadata.TableName)
+	if err != nil {
+		p.logger.Errorf("failed to acquire cleanup advisory lock: %v", err)
+		return
+	}
+	if !acquired {
+		// Another sidecar is already running cleanup for this table.
+		return
+	}
+	defer func() {
+		if unlockErr := p.AdvisoryUnlock(ctx, p.metadata.TableName); unlockErr != nil {
+			p.logger.Errorf("failed to release cleanup advisory lock: %v", unlockErr)
+		}
+	}()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expiredate IS NOT NULL AND expiredate <= now()`, p.metadata.TableName)
+	if _, err = p.db.ExecContext(ctx, query); err != nil {
+		p.logger.Errorf("failed to delete expired rows from %s: %v", p.metadata.TableName, err)
+	}
+}
+
+// TryAdvisoryLock attempts to acquire a session-level Postgres advisory lock
+// named after name. It also satisfies bindings/cron's PGAdvisoryLocker, so a
+// Postgres state store can double as that binding's leader-election backend.
+//
+// Session-level advisory locks are tied to the backend connection that
+// acquired them, not to *sql.DB as a whole, so each name's lock is taken on
+// its own *sql.Conn reserved from the pool and held in advisoryLockConns
+// until AdvisoryUnlock releases it on that same connection. Acquiring
+// through p.db directly would let the pool hand AdvisoryUnlock a different
+// connection, leaving the lock held forever; sharing one reserved connection
+// across multiple names would have the same effect, since releasing one
+// name's connection would silently drop any other name's lock still held on
+// it.
+func (p *PostgresDBAccess) TryAdvisoryLock(ctx context.Context, name string) (bool, error) {
+	p.advisoryLockMu.Lock()
+	defer p.advisoryLockMu.Unlock()
+
+	if _, held := p.advisoryLockConns[name]; held {
+		// This instance already holds name's lock.
+		return true, nil
+	}
+
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve advisory lock connection for %s: %w", name, err)
+	}
+
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, name).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("failed to acquire advisory lock %s: %w", name, err)
+	}
+	if !acquired {
+		// Didn't get the lock: release the reserved connection rather than
+		// pinning it for nothing until the next attempt.
+		conn.Close()
+		return false, nil
+	}
+
+	if p.advisoryLockConns == nil {
+		p.advisoryLockConns = make(map[string]*sql.Conn)
+	}
+	p.advisoryLockConns[name] = conn
+
+	return true, nil
+}
+
+// AdvisoryUnlock releases a lock previously acquired with TryAdvisoryLock, on
+// the same connection that acquired it.
+func (p *PostgresDBAccess) AdvisoryUnlock(ctx context.Context, name string) error {
+	p.advisoryLockMu.Lock()
+	defer p.advisoryLockMu.Unlock()
+
+	conn, held := p.advisoryLockConns[name]
+	if !held {
+		return nil
+	}
+	defer func() {
+		conn.Close()
+		delete(p.advisoryLockConns, name)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, name); err != nil {
+		return fmt.Errorf("failed to release advisory lock %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (p *PostgresDBAccess) ensureStateTable(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (
+			key TEXT NOT NULL PRIMARY KEY,
+			value JSONB NOT NULL,
+			isbinary BOOLEAN NOT NULL DEFAULT FALSE,
+			etag BIGINT NOT NULL DEFAULT 1,
+			insertdate TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+			updatedate TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+			expiredate TIMESTAMP WITH TIME ZONE
+		)`, p.metadata.TableName))
+	if err != nil {
+		return fmt.Errorf("failed to ensure state table exists: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a single state row by key.
+func (p *PostgresDBAccess) Get(ctx context.Context, req *state.GetRequest) (*state.GetResponse, error) {
+	if req.Key == "" {
+		return nil, fmt.Errorf("missing key in get operation")
+	}
+
+	query := fmt.Sprintf(
+		`SELECT value, etag FROM %s WHERE key = $1 AND (expiredate IS NULL OR expiredate > now())`,
+		p.metadata.TableName)
+	row := p.db.QueryRowContext(ctx, query, req.Key)
+
+	var (
+		value []byte
+		etag  int64
+	)
+	err := row.Scan(&value, &etag)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &state.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", req.Key, err)
+	}
+
+	etagStr := strconv.FormatInt(etag, 10)
+
+	return &state.GetResponse{
+		Data: value,
+		ETag: &etagStr,
+	}, nil
+}
+
+// BulkGet retrieves multiple state rows by key.
+func (p *PostgresDBAccess) BulkGet(ctx context.Context, req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	responses := make([]state.BulkGetResponse, 0, len(req))
+	for _, r := range req {
+		r := r
+		resp, err := p.Get(ctx, &r)
+		bulkResp := state.BulkGetResponse{Key: r.Key}
+		if err != nil {
+			bulkResp.Error = err.Error()
+		} else {
+			bulkResp.Data = resp.Data
+			bulkResp.ETag = resp.ETag
+		}
+		responses = append(responses, bulkResp)
+	}
+
+	return responses, nil
+}
+
+// getSet validates that operation carries a well-formed SetRequest.
+func getSet(operation state.TransactionalStateOperation) (state.SetRequest, error) {
+	set, ok := operation.Request.(state.SetRequest)
+	if !ok {
+		return set, fmt.Errorf("expecting set request, got %T", operation.Request)
+	}
+	if set.Key == "" {
+		return set, fmt.Errorf("missing key in set operation")
+	}
+
+	return set, nil
+}
+
+// getDelete validates that operation carries a well-formed DeleteRequest.
+func getDelete(operation state.TransactionalStateOperation) (state.DeleteRequest, error) {
+	del, ok := operation.Request.(state.DeleteRequest)
+	if !ok {
+		return del, fmt.Errorf("expecting delete request, got %T", operation.Request)
+	}
+	if del.Key == "" {
+		return del, fmt.Errorf("missing key in delete operation")
+	}
+
+	return del, nil
+}
+
+// setValue inserts or updates a single row, enforcing req.ETag (when set) and
+// state.FirstWrite concurrency (when requested) as part of the SQL itself so
+// the check and the write happen atomically.
+func (p *PostgresDBAccess) setValue(ctx context.Context, db dbExecutor, req state.SetRequest) error {
+	if req.Key == "" {
+		return fmt.Errorf("missing key in set operation")
+	}
+	if req.Value == nil || req.Value == "" {
+		return fmt.Errorf("missing value in set operation")
+	}
+
+	value, err := marshalValue(req.Value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value for key %s: %w", req.Key, err)
+	}
+
+	expiresAt, err := parseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid ttlInSeconds for key %s: %w", req.Key, err)
+	}
+
+	var (
+		query string
+		args  []any
+	)
+
+	switch {
+	case req.ETag != nil && *req.ETag != "":
+		etag, etagErr := strconv.ParseInt(*req.ETag, 10, 64)
+		if etagErr != nil {
+			return fmt.Errorf("invalid etag %q for key %s: %w", *req.ETag, req.Key, etagErr)
+		}
+		query = fmt.Sprintf(
+			`UPDATE %s SET value = $1, expiredate = $2, etag = etag + 1, updatedate = now() WHERE key = $3 AND etag = $4`,
+			p.metadata.TableName)
+		args = []any{value, expiresAt, req.Key, etag}
+	case req.Options.Concurrency == state.FirstWrite:
+		query = fmt.Sprintf(`INSERT INTO %s (key, value, expiredate) VALUES ($1, $2, $3)`, p.metadata.TableName)
+		args = []any{req.Key, value, expiresAt}
+	default:
+		query = fmt.Sprintf(
+			`INSERT INTO %[1]s (key, value, expiredate) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expiredate = EXCLUDED.expiredate, etag = %[1]s.etag + 1, updatedate = now()`,
+			p.metadata.TableName)
+		args = []any{req.Key, value, expiresAt}
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolationCode {
+			// The FirstWrite branch above has no ON CONFLICT clause, so an
+			// insert over an existing key surfaces as a unique-violation
+			// rather than rows-affected == 0; report it the same way as the
+			// ETag-mismatch case so callers that type-switch on
+			// state.ETagError see a consistent concurrency conflict.
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("key %s already exists: %w", req.Key, err))
+		}
+		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+	}
+
+	if req.ETag != nil && *req.ETag != "" {
+		rows, raErr := result.RowsAffected()
+		if raErr != nil {
+			return raErr
+		}
+		if rows == 0 {
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+		}
+	}
+
+	return nil
+}
+
+// deleteValue deletes a single row, enforcing req.ETag (when set) as part of the SQL itself.
+func (p *PostgresDBAccess) deleteValue(ctx context.Context, db dbExecutor, req state.DeleteRequest) error {
+	if req.Key == "" {
+		return fmt.Errorf("missing key in delete operation")
+	}
+
+	var (
+		query string
+		args  []any
+	)
+
+	if req.ETag != nil && *req.ETag != "" {
+		etag, err := strconv.ParseInt(*req.ETag, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid etag %q for key %s: %w", *req.ETag, req.Key, err)
+		}
+		query = fmt.Sprintf(`DELETE FROM %s WHERE key = $1 AND etag = $2`, p.metadata.TableName)
+		args = []any{req.Key, etag}
+	} else {
+		query = fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, p.metadata.TableName)
+		args = []any{req.Key}
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+	}
+
+	if req.ETag != nil && *req.ETag != "" {
+		rows, raErr := result.RowsAffected()
+		if raErr != nil {
+			return raErr
+		}
+		if rows == 0 {
+			return state.NewETagError(state.ETagMismatch, fmt.Errorf("etag mismatch for key %s", req.Key))
+		}
+	}
+
+	return nil
+}
+
+// ExecuteMulti runs a set of upsert/delete operations atomically, rolling
+// back the whole batch if any operation is invalid or fails an ETag check.
+func (p *PostgresDBAccess) ExecuteMulti(ctx context.Context, request *state.TransactionalStateRequest) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, op := range request.Operations {
+		switch op.Operation {
+		case state.Upsert:
+			set, setErr := getSet(op)
+			if setErr != nil {
+				tx.Rollback()
+				return setErr
+			}
+			if setErr = p.setValue(ctx, tx, set); setErr != nil {
+				tx.Rollback()
+				return setErr
+			}
+		case state.Delete:
+			del, delErr := getDelete(op)
+			if delErr != nil {
+				tx.Rollback()
+				return delErr
+			}
+			if delErr = p.deleteValue(ctx, tx, del); delErr != nil {
+				tx.Rollback()
+				return delErr
+			}
+		default:
+			tx.Rollback()
+			return fmt.Errorf("unsupported operation: %s", op.Operation)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// BulkSet upserts multiple rows atomically.
+func (p *PostgresDBAccess) BulkSet(ctx context.Context, req []state.SetRequest) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, set := range req {
+		if err = p.setValue(ctx, tx, set); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// BulkDelete deletes multiple rows atomically.
+func (p *PostgresDBAccess) BulkDelete(ctx context.Context, req []state.DeleteRequest) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, del := range req {
+		if err = p.deleteValue(ctx, tx, del); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Close stops the background cleanup goroutine, if running, and closes the
+// database connection pool.
+func (p *PostgresDBAccess) Close() error {
+	if p.closeCh != nil {
+		p.closeOnce.Do(func() { close(p.closeCh) })
+		p.wg.Wait()
+	}
+
+	if p.db == nil {
+		return nil
+	}
+
+	return p.db.Close()
+}
+
+// parseTTL reads the ttlInSeconds state metadata key, returning the absolute
+// expiry time to store, or nil for no expiration.
+func parseTTL(meta map[string]string) (*time.Time, error) {
+	raw, ok := meta["ttlInSeconds"]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	ttl, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Second)
+
+	return &expiresAt, nil
+}
+
+// marshalValue normalizes a state value into the JSON payload stored in the JSONB column.
+func marshalValue(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return json.Marshal(val)
+	case string:
+		if json.Valid([]byte(val)) {
+			return []byte(val), nil
+		}
+		return json.Marshal(val)
+	default:
+		return json.Marshal(val)
+	}
+}
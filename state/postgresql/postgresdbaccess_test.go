@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/dapr/components-contrib/metadata"
@@ -435,6 +436,166 @@ func TestValidBulkDelete(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestMultiSetRollsBackOnStaleETag(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 0))
+	m.mock.ExpectRollback()
+
+	etag := "1"
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Upsert,
+		Request:   state.SetRequest{Key: "key1", Value: "value1", ETag: &etag},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	var etagErr *state.ETagError
+	assert.ErrorAs(t, err, &etagErr)
+}
+
+func TestMultiSetCommitsOnMatchingETag(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	m.mock.ExpectCommit()
+
+	etag := "1"
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Upsert,
+		Request:   state.SetRequest{Key: "key1", Value: "value1", ETag: &etag},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestMultiDeleteRollsBackOnStaleETag(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 0))
+	m.mock.ExpectRollback()
+
+	etag := "1"
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Delete,
+		Request:   state.DeleteRequest{Key: "key1", ETag: &etag},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	var etagErr *state.ETagError
+	assert.ErrorAs(t, err, &etagErr)
+}
+
+func TestMultiDeleteCommitsOnMatchingETag(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+	m.mock.ExpectCommit()
+
+	etag := "1"
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Delete,
+		Request:   state.DeleteRequest{Key: "key1", ETag: &etag},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestFirstWriteInsertsWhenKeyIsNew(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("INSERT INTO").WillReturnResult(sqlmock.NewResult(1, 1))
+	m.mock.ExpectCommit()
+
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Upsert,
+		Request: state.SetRequest{
+			Key:     "key1",
+			Value:   "value1",
+			Options: state.SetStateOption{Concurrency: state.FirstWrite},
+		},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestFirstWriteRollsBackAsETagMismatchWhenKeyExists(t *testing.T) {
+	// Arrange
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	m.mock.ExpectBegin()
+	m.mock.ExpectExec("INSERT INTO").
+		WillReturnError(&pgconn.PgError{Code: pgUniqueViolationCode, Message: "duplicate key value violates unique constraint"})
+	m.mock.ExpectRollback()
+
+	var operations []state.TransactionalStateOperation
+	operations = append(operations, state.TransactionalStateOperation{
+		Operation: state.Upsert,
+		Request: state.SetRequest{
+			Key:     "key1",
+			Value:   "value1",
+			Options: state.SetStateOption{Concurrency: state.FirstWrite},
+		},
+	})
+
+	// Act
+	err := m.pgDba.ExecuteMulti(context.Background(), &state.TransactionalStateRequest{
+		Operations: operations,
+	})
+
+	// Assert
+	var etagErr *state.ETagError
+	assert.ErrorAs(t, err, &etagErr)
+}
+
 func createSetRequest() state.SetRequest {
 	return state.SetRequest{
 		Key:   randomKey(),
@@ -558,4 +719,27 @@ func TestParseMetadata(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Nil(t, p.cleanupInterval)
 	})
+
+	t.Run("no queryIndexKeys", func(t *testing.T) {
+		p := &PostgresDBAccess{}
+		props := map[string]string{
+			"connectionString": "foo",
+		}
+
+		err := p.ParseMetadata(state.Metadata{Base: metadata.Base{Properties: props}})
+		assert.NoError(t, err)
+		assert.Nil(t, p.metadata.QueryIndexKeys)
+	})
+
+	t.Run("queryIndexKeys", func(t *testing.T) {
+		p := &PostgresDBAccess{}
+		props := map[string]string{
+			"connectionString": "foo",
+			"queryIndexKeys":   "person.org, state",
+		}
+
+		err := p.ParseMetadata(state.Metadata{Base: metadata.Base{Properties: props}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"person.org", "state"}, p.metadata.QueryIndexKeys)
+	})
 }
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgresql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
+)
+
+func TestTranslateFiltersEQ(t *testing.T) {
+	tr := &queryTranslator{tableName: defaultTableName}
+	clause, args, err := tr.translateFilters(&query.EQFilter{Key: "person.org", Value: "A"})
+	require.NoError(t, err)
+	assert.Equal(t, "value #>> '{person,org}' = $1", clause)
+	assert.Equal(t, []any{"A"}, args)
+}
+
+func TestTranslateFiltersIN(t *testing.T) {
+	tr := &queryTranslator{tableName: defaultTableName}
+	clause, args, err := tr.translateFilters(&query.INFilter{Key: "state", Values: []any{"CA", "WA"}})
+	require.NoError(t, err)
+	assert.Equal(t, "value #>> '{state}' IN ($1, $2)", clause)
+	assert.Equal(t, []any{"CA", "WA"}, args)
+}
+
+func TestTranslateFiltersANDOR(t *testing.T) {
+	tr := &queryTranslator{tableName: defaultTableName}
+	clause, args, err := tr.translateFilters(&query.ANDFilter{
+		Filters: []query.Filters{
+			&query.EQFilter{Key: "person.org", Value: "A"},
+			&query.ORFilter{Filters: []query.Filters{
+				&query.EQFilter{Key: "state", Value: "CA"},
+				&query.EQFilter{Key: "state", Value: "WA"},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "(value #>> '{person,org}' = $1) AND ((value #>> '{state}' = $2) OR (value #>> '{state}' = $3))", clause)
+	assert.Equal(t, []any{"A", "CA", "WA"}, args)
+}
+
+func TestTranslateSort(t *testing.T) {
+	clause, err := translateSort(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", clause)
+
+	clause, err = translateSort([]query.Sorting{
+		{Key: "person.id", Order: "ASC"},
+		{Key: "state", Order: "DESC"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "value #>> '{person,id}' ASC, value #>> '{state}' DESC", clause)
+}
+
+// TestTranslateRejectsInvalidKeys guards against SQL injection through a
+// filter/sort Key: since jsonPath splices its result directly into the SQL
+// text, a key like `a}' OR pg_sleep(5)--` must be rejected rather than
+// concatenated verbatim.
+func TestTranslateRejectsInvalidKeys(t *testing.T) {
+	maliciousKey := `a}' OR pg_sleep(5)--`
+
+	t.Run("EQFilter", func(t *testing.T) {
+		tr := &queryTranslator{tableName: defaultTableName}
+		_, _, err := tr.translateFilters(&query.EQFilter{Key: maliciousKey, Value: "A"})
+		assert.Error(t, err)
+	})
+
+	t.Run("INFilter", func(t *testing.T) {
+		tr := &queryTranslator{tableName: defaultTableName}
+		_, _, err := tr.translateFilters(&query.INFilter{Key: maliciousKey, Values: []any{"A"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		_, err := translateSort([]query.Sorting{{Key: maliciousKey, Order: "ASC"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryTokenRoundTrip(t *testing.T) {
+	token := encodeQueryToken("key10", 10)
+	offset, err := decodeQueryToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 10, offset)
+
+	offset, err = decodeQueryToken("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, offset)
+
+	_, err = decodeQueryToken("not-base64!!")
+	assert.Error(t, err)
+}
+
+// TestEnsureQueryIndexCreatesPerKeyExpressionIndex guards against
+// ensureQueryIndex going back to a single GIN index over the whole value
+// column: that index type can't serve the #>> comparisons Query emits, so
+// it must instead create one btree expression index per configured key,
+// matching the exact expression used in the WHERE/ORDER BY clause.
+func TestEnsureQueryIndexCreatesPerKeyExpressionIndex(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+	m.pgDba.metadata.TableName = defaultTableName
+	m.pgDba.metadata.QueryIndexKeys = []string{"person.org", "state"}
+
+	m.mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_state_person_org ON state \(\(value #>> '\{person,org\}'\)\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	m.mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_state_state ON state \(\(value #>> '\{state\}'\)\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := m.pgDba.ensureQueryIndex(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, m.mock.ExpectationsWereMet())
+}
+
+func TestEnsureQueryIndexRejectsInvalidKey(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+	m.pgDba.metadata.TableName = defaultTableName
+	m.pgDba.metadata.QueryIndexKeys = []string{`a}' OR pg_sleep(5)--`}
+
+	err := m.pgDba.ensureQueryIndex(context.Background())
+	assert.Error(t, err)
+}
+
+func TestQueryReturnsTokenWhenMoreRowsRemain(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+
+	rows := sqlmock.NewRows([]string{"key", "value", "etag"}).
+		AddRow("key1", []byte(`{"state":"CA"}`), 1).
+		AddRow("key2", []byte(`{"state":"CA"}`), 1)
+	m.mock.ExpectQuery("SELECT key, value, etag FROM").WillReturnRows(rows)
+
+	resp, err := m.pgDba.Query(context.Background(), &state.QueryRequest{
+		Query: query.Query{Page: query.Pagination{Limit: 1}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "key1", resp.Results[0].Key)
+	assert.NotEmpty(t, resp.Token)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTTL(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		expiresAt, err := parseTTL(map[string]string{})
+		require.NoError(t, err)
+		assert.Nil(t, expiresAt)
+	})
+
+	t.Run("zero disables expiry", func(t *testing.T) {
+		expiresAt, err := parseTTL(map[string]string{"ttlInSeconds": "0"})
+		require.NoError(t, err)
+		assert.Nil(t, expiresAt)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseTTL(map[string]string{"ttlInSeconds": "NaN"})
+		assert.Error(t, err)
+	})
+
+	t.Run("positive", func(t *testing.T) {
+		expiresAt, err := parseTTL(map[string]string{"ttlInSeconds": "60"})
+		require.NoError(t, err)
+		require.NotNil(t, expiresAt)
+		assert.True(t, expiresAt.After(time.Now()))
+	})
+}
+
+func TestCleanupExpiredRunsDeleteWhenLockAcquired(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+	m.pgDba.metadata.TableName = defaultTableName
+
+	m.mock.ExpectQuery("pg_try_advisory_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	m.mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 2))
+	m.mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	m.pgDba.cleanupExpired()
+
+	assert.NoError(t, m.mock.ExpectationsWereMet())
+}
+
+func TestCleanupExpiredSkipsDeleteWhenLockNotAcquired(t *testing.T) {
+	m, _ := mockDatabase(t)
+	defer m.db.Close()
+	m.pgDba.metadata.TableName = defaultTableName
+
+	m.mock.ExpectQuery("pg_try_advisory_lock").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	m.pgDba.cleanupExpired()
+
+	assert.NoError(t, m.mock.ExpectationsWereMet())
+}